@@ -0,0 +1,81 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"maunium.net/go/mautrix-appservice"
+)
+
+// AppServiceConfig is the `appservice` section of config.yaml: how the
+// bridge registers with and is reached by the homeserver.
+type AppServiceConfig struct {
+	Address  string `yaml:"address"`
+	Hostname string `yaml:"hostname"`
+	Port     uint16 `yaml:"port"`
+
+	Database DatabaseConfig `yaml:"database"`
+	Bot      BotConfig      `yaml:"bot"`
+
+	// StateStore is the path to the file used to cache joined-room state.
+	StateStore string `yaml:"state_store_path"`
+
+	ASToken string `yaml:"as_token"`
+	HSToken string `yaml:"hs_token"`
+
+	ID string `yaml:"id"`
+}
+
+// DatabaseConfig is the `appservice.database` section of config.yaml.
+type DatabaseConfig struct {
+	Type string `yaml:"type"`
+	URI  string `yaml:"uri"`
+}
+
+// BotConfig controls the appservice bot's own Matrix profile.
+type BotConfig struct {
+	Username    string `yaml:"username"`
+	Displayname string `yaml:"displayname"`
+	Avatar      string `yaml:"avatar"`
+}
+
+// MakeAppService builds an appservice.AppService from the config, ready to
+// have Init called on it.
+func (config *Config) MakeAppService() (*appservice.AppService, error) {
+	as := appservice.Create()
+	as.HomeserverDomain = config.AppService.Hostname
+	as.HomeserverURL = config.AppService.Address
+	as.Host.Hostname = config.AppService.Hostname
+	as.Host.Port = config.AppService.Port
+	as.Registration = &appservice.Registration{
+		ID:              config.AppService.ID,
+		AS_Token:        config.AppService.ASToken,
+		HS_Token:        config.AppService.HSToken,
+		SenderLocalpart: config.AppService.Bot.Username,
+	}
+	return as, nil
+}
+
+// NewRegistration builds the appservice registration file that has to be
+// added to the homeserver config, generating fresh tokens.
+func (config *Config) NewRegistration() (*appservice.Registration, error) {
+	registration := appservice.CreateRegistration()
+	registration.ID = config.AppService.ID
+	registration.SenderLocalpart = config.AppService.Bot.Username
+	config.AppService.ASToken = registration.AS_Token
+	config.AppService.HSToken = registration.HS_Token
+	return registration, nil
+}
@@ -0,0 +1,37 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import "maunium.net/go/mautrix-whatsapp/sessionstore"
+
+// BridgeConfig is the `bridge` section of config.yaml: everything that's
+// safe to hot-swap via Bridge.Reload without dropping WhatsApp sessions or
+// re-initializing portals.
+type BridgeConfig struct {
+	UsernameTemplate    string `yaml:"username_template"`
+	DisplaynameTemplate string `yaml:"displayname_template"`
+
+	// SessionStore selects and configures where WhatsApp session
+	// credentials are persisted (see package sessionstore). Changing the
+	// backend requires a restart; it's only read once, at Bridge.Init.
+	SessionStore sessionstore.Config `yaml:"session_store"`
+
+	// DegradedThreshold is the fraction of loaded users (0-1) that must be
+	// disconnected from WhatsApp before /readyz reports "degraded" instead
+	// of "ok". Zero means the built-in default is used.
+	DegradedThreshold float64 `yaml:"degraded_threshold"`
+}
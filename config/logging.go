@@ -0,0 +1,48 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	log "maunium.net/go/maulogger/v2"
+)
+
+// LogConfig is the `logging` section of config.yaml.
+type LogConfig struct {
+	Directory      string `yaml:"directory"`
+	FileNameFormat string `yaml:"file_name_format"`
+	FileDateFormat string `yaml:"file_date_format"`
+	FileMode       uint32 `yaml:"file_mode"`
+
+	PrintLevel int `yaml:"print_level"`
+}
+
+// Configure applies Directory and PrintLevel to logger, both at startup and
+// after Bridge.Reload; FileNameFormat, FileDateFormat and FileMode are not
+// applied. PrintLevel takes effect immediately, while Directory is only used
+// when the log file is opened, which Bridge.Init does once at startup.
+func (logConfig LogConfig) Configure(logger log.Logger) {
+	basic, ok := logger.(*log.BasicLogger)
+	if !ok {
+		return
+	}
+	if len(logConfig.Directory) > 0 {
+		basic.FileFormat = func(now string, i int) string {
+			return logConfig.Directory + "/" + now + ".log"
+		}
+	}
+	basic.PrintLevel = logConfig.PrintLevel
+}
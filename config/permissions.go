@@ -0,0 +1,50 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import "strings"
+
+// PermissionLevel is the access level granted to a Matrix user or server.
+type PermissionLevel int
+
+const (
+	PermissionDefault PermissionLevel = iota
+	PermissionUser
+	PermissionAdmin
+)
+
+// PermissionConfig is the `permissions` section of config.yaml: a map from
+// a Matrix user ID, a whole homeserver ("*:example.com"), or "*" to the
+// permission level granted to matching users.
+type PermissionConfig map[string]PermissionLevel
+
+// GetPermissionLevel returns the permission level granted to mxid, checking
+// the exact user ID, then the user's homeserver, then the wildcard entry.
+func (perms PermissionConfig) GetPermissionLevel(mxid string) PermissionLevel {
+	if level, ok := perms[mxid]; ok {
+		return level
+	}
+	if idx := strings.IndexByte(mxid, ':'); idx >= 0 {
+		if level, ok := perms["*"+mxid[idx:]]; ok {
+			return level
+		}
+	}
+	if level, ok := perms["*"]; ok {
+		return level
+	}
+	return PermissionDefault
+}
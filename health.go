@@ -0,0 +1,221 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthBeatTimeout is how long save_loop can go without a heartbeat
+// before it's considered dead by /livez. This only applies to save_loop:
+// it ticks on a fixed interval regardless of bridge activity, so going
+// quiet for this long means it's actually stuck. event_processor has no
+// such guarantee - a bridge can be legitimately idle for hours - so its
+// liveness is tracked separately, by whether its goroutine is still
+// running at all rather than by how recently it last did something.
+const healthBeatTimeout = 3 * saveLoopInterval
+
+// defaultDegradedThreshold is used when config.Bridge.DegradedThreshold is
+// unset (zero value).
+const defaultDegradedThreshold = 0.5
+
+type componentStatus struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type healthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+type loopState struct {
+	running  bool
+	lastBeat time.Time
+	hasBeat  bool
+}
+
+// Health tracks the bridge's background loops and answers Kubernetes
+// liveness/readiness probes.
+type Health struct {
+	bridge *Bridge
+
+	lock  sync.Mutex
+	loops map[string]*loopState
+}
+
+func NewHealth(bridge *Bridge) *Health {
+	return &Health{
+		bridge: bridge,
+		loops:  make(map[string]*loopState),
+	}
+}
+
+func (h *Health) loop(name string) *loopState {
+	s, ok := h.loops[name]
+	if !ok {
+		s = &loopState{}
+		h.loops[name] = s
+	}
+	return s
+}
+
+// Beat records that the named loop (e.g. "save_loop") completed another
+// tick, and that the named loop is running.
+func (h *Health) Beat(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s := h.loop(name)
+	s.running = true
+	s.hasBeat = true
+	s.lastBeat = time.Now()
+}
+
+// MarkStopped records that the named loop's goroutine has returned,
+// whether that's an intentional shutdown or a crash. It's what lets
+// event_processor's liveness check catch a dead goroutine without relying
+// on a staleness timeout that idle-but-healthy bridges would trip.
+func (h *Health) MarkStopped(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.loop(name).running = false
+}
+
+// aliveTicking reports a loop as dead once it's gone longer than timeout
+// without a heartbeat. Only valid for loops that beat on a fixed interval
+// regardless of bridge activity (e.g. save_loop).
+func (h *Health) aliveTicking(name string, timeout time.Duration) componentStatus {
+	h.lock.Lock()
+	s := *h.loop(name)
+	h.lock.Unlock()
+
+	if !s.hasBeat {
+		return componentStatus{Status: "error", Detail: "no heartbeat received yet"}
+	} else if !s.running {
+		return componentStatus{Status: "error", Detail: "loop stopped unexpectedly"}
+	} else if since := time.Since(s.lastBeat); since > timeout {
+		return componentStatus{Status: "error", Detail: "last heartbeat " + since.String() + " ago"}
+	}
+	return componentStatus{Status: "ok"}
+}
+
+// aliveRunning reports a loop as dead only if it never started or its
+// goroutine has returned - never just because it's been quiet, since
+// quiet can mean "idle" as easily as "hung". Used for event_processor,
+// which only does something when the homeserver has something to deliver.
+func (h *Health) aliveRunning(name string) componentStatus {
+	h.lock.Lock()
+	s := *h.loop(name)
+	h.lock.Unlock()
+
+	if !s.hasBeat {
+		return componentStatus{Status: "error", Detail: "never started"}
+	} else if !s.running {
+		return componentStatus{Status: "error", Detail: "stopped unexpectedly"}
+	}
+	detail := ""
+	if !s.lastBeat.IsZero() {
+		detail = "last event " + time.Since(s.lastBeat).Round(time.Second).String() + " ago"
+	}
+	return componentStatus{Status: "ok", Detail: detail}
+}
+
+func (h *Health) registerRoutes() {
+	h.bridge.AS.Router.HandleFunc("/livez", h.handleLivez).Methods(http.MethodGet)
+	h.bridge.AS.Router.HandleFunc("/readyz", h.handleReadyz).Methods(http.MethodGet)
+}
+
+func (h *Health) handleLivez(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, map[string]componentStatus{
+		"save_loop":       h.aliveTicking("save_loop", healthBeatTimeout),
+		"event_processor": h.aliveRunning("event_processor"),
+	})
+}
+
+func (h *Health) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, map[string]componentStatus{
+		"database":    h.checkDatabase(),
+		"state_store": h.checkStateStore(),
+		"whatsapp":    h.checkWhatsAppConnections(),
+	})
+}
+
+func (h *Health) checkDatabase() componentStatus {
+	if h.bridge.DB == nil {
+		return componentStatus{Status: "error", Detail: "database not initialized"}
+	} else if err := h.bridge.DB.DB.Ping(); err != nil {
+		return componentStatus{Status: "error", Detail: err.Error()}
+	}
+	return componentStatus{Status: "ok"}
+}
+
+func (h *Health) checkStateStore() componentStatus {
+	if h.bridge.StateStore == nil {
+		return componentStatus{Status: "error", Detail: "state store not loaded"}
+	}
+	return componentStatus{Status: "ok"}
+}
+
+func (h *Health) checkWhatsAppConnections() componentStatus {
+	h.bridge.usersLock.Lock()
+	total := len(h.bridge.usersByJID)
+	disconnected := 0
+	for _, user := range h.bridge.usersByJID {
+		if user.Conn == nil {
+			disconnected++
+		}
+	}
+	h.bridge.usersLock.Unlock()
+
+	if total == 0 {
+		return componentStatus{Status: "ok"}
+	}
+
+	threshold := h.bridge.BridgeConfig().DegradedThreshold
+	if threshold <= 0 {
+		threshold = defaultDegradedThreshold
+	}
+
+	fraction := float64(disconnected) / float64(total)
+	if fraction >= threshold {
+		return componentStatus{Status: "degraded", Detail: "too many disconnected users"}
+	}
+	return componentStatus{Status: "ok"}
+}
+
+func writeHealth(w http.ResponseWriter, components map[string]componentStatus) {
+	status := "ok"
+	for _, component := range components {
+		if component.Status == "error" {
+			status = "error"
+			break
+		} else if component.Status == "degraded" {
+			status = "degraded"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status == "error" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = json.NewEncoder(w).Encode(healthResponse{Status: status, Components: components})
+}
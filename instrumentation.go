@@ -0,0 +1,50 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	mautrix "maunium.net/go/mautrix"
+)
+
+// instrumentedEventTypes are the event types MatrixHandler registers
+// handlers for. We register our own handler alongside those so every
+// Matrix event that actually reaches the event processor's dispatch path
+// is counted.
+var instrumentedEventTypes = []mautrix.EventType{
+	mautrix.EventMessage,
+	mautrix.EventSticker,
+	mautrix.EventReaction,
+	mautrix.EventRedaction,
+	mautrix.StateMember,
+}
+
+// registerEventInstrumentation hooks TrackMatrixEvent into the real
+// per-event dispatch path. It also beats "event_processor" on every event,
+// but that's purely informational (surfaced as a "last event" detail on
+// /livez) - a quiet bridge is not a dead one, so liveness itself is
+// tracked by whether the event processor's goroutine is still running,
+// set up in Bridge.Start.
+func (bridge *Bridge) registerEventInstrumentation() {
+	for _, evtType := range instrumentedEventTypes {
+		bridge.EventProcessor.On(evtType, bridge.trackMatrixEvent)
+	}
+}
+
+func (bridge *Bridge) trackMatrixEvent(evt *mautrix.Event) {
+	bridge.Metrics.TrackMatrixEvent(evt.Type.String(), true)
+	bridge.Health.Beat("event_processor")
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	flag "maunium.net/go/mauflag"
@@ -30,6 +31,7 @@ import (
 
 	"maunium.net/go/mautrix-whatsapp/config"
 	"maunium.net/go/mautrix-whatsapp/database"
+	"maunium.net/go/mautrix-whatsapp/sessionstore"
 	"maunium.net/go/mautrix-whatsapp/types"
 )
 
@@ -65,12 +67,17 @@ func (bridge *Bridge) GenerateRegistration() {
 type Bridge struct {
 	AS             *appservice.AppService
 	EventProcessor *appservice.EventProcessor
+	DB             *database.Database
 	MatrixHandler  *MatrixHandler
 	Config         *config.Config
 	Log            log.Logger
 	StateStore     *AutosavingStateStore
 	Bot            *appservice.IntentAPI
 	Formatter      *Formatter
+	Metrics        *Metrics
+	Health         *Health
+	SessionStore   sessionstore.SessionStore
+	liveConfig     atomic.Value
 
 	usersByMXID         map[types.MatrixUserID]*User
 	usersByJID          map[types.WhatsAppID]*User
@@ -140,17 +147,29 @@ func (bridge *Bridge) Init() {
 		os.Exit(13)
 	}
 	bridge.AS.StateStore = bridge.StateStore
+	bridge.initLiveConfig()
 
 	bridge.Log.Debugln("Initializing database")
+	bridge.DB, err = database.New(bridge.Config.AppService.Database.Type, bridge.Config.AppService.Database.URI)
+	if err != nil {
+		bridge.Log.Fatalln("Failed to initialize database:", err)
+		os.Exit(14)
+	}
+
+	bridge.SessionStore, err = sessionstore.New(bridge.BridgeConfig().SessionStore, bridge.DB)
+	if err != nil {
+		bridge.Log.Fatalln("Failed to initialize session store:", err)
+		os.Exit(15)
+	}
 	if err = bridge.LoadUsers(); err != nil {
 		bridge.Log.Fatalln("Failed to load users:", err)
-		os.Exit(14)
+		os.Exit(16)
 	} else if err = bridge.LoadPortals(); err != nil {
 		bridge.Log.Fatalln("Failed to load portals:", err)
-		os.Exit(15)
+		os.Exit(17)
 	} else if err = bridge.LoadPuppets(); err != nil {
 		bridge.Log.Fatalln("Failed to load puppets:", err)
-		os.Exit(16)
+		os.Exit(18)
 	}
 
 	bridge.Log.Debugln("Initializing Matrix event processor")
@@ -158,16 +177,31 @@ func (bridge *Bridge) Init() {
 	bridge.Log.Debugln("Initializing Matrix event handler")
 	bridge.MatrixHandler = NewMatrixHandler(bridge)
 	bridge.Formatter = NewFormatter(bridge)
+	bridge.Metrics = NewMetrics(bridge)
+	bridge.registerReloadRoute()
+	bridge.Health = NewHealth(bridge)
+	bridge.Health.registerRoutes()
+	bridge.registerEventInstrumentation()
 }
 
 func (bridge *Bridge) Start() {
 	bridge.Log.Debugln("Starting application service HTTP server")
 	go bridge.AS.Start()
 	bridge.Log.Debugln("Starting event processor")
-	go bridge.EventProcessor.Start()
+	bridge.Health.Beat("event_processor")
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				bridge.Log.Errorln("Event processor panicked:", r)
+			}
+			bridge.Health.MarkStopped("event_processor")
+		}()
+		bridge.EventProcessor.Start()
+	}()
 	go bridge.UpdateBotProfile()
 	go bridge.StartUsers()
 	go bridge.SaveLoop()
+	bridge.Metrics.Start()
 }
 
 func (bridge *Bridge) UpdateBotProfile() {
@@ -213,6 +247,7 @@ func (bridge *Bridge) StartUsers() {
 
 func (bridge *Bridge) Stop() {
 	bridge.stopSaveLoop <- true
+	bridge.Metrics.Stop()
 	bridge.AS.Stop()
 	bridge.EventProcessor.Stop()
 	for _, user := range bridge.usersByJID {
@@ -251,9 +286,17 @@ func (bridge *Bridge) Main() {
 	bridge.Start()
 	bridge.Log.Infoln("Bridge started!")
 
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range c {
+		if sig == syscall.SIGHUP {
+			if err := bridge.Reload(); err != nil {
+				bridge.Log.Errorln("Failed to reload config:", err)
+			}
+			continue
+		}
+		break
+	}
 
 	bridge.Log.Infoln("Interrupt received, stopping...")
 	bridge.Stop()
@@ -0,0 +1,227 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	log "maunium.net/go/maulogger/v2"
+)
+
+// Version and Commit are set at build time using -ldflags.
+var Version = "unknown"
+var Commit = "unknown"
+var BuildTime = "unknown"
+
+var (
+	metricConnectedUsers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "bridge_connected_users",
+		Help: "Number of users with an active WhatsApp connection",
+	})
+	metricLoadedUsers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "bridge_users_loaded",
+		Help: "Number of users loaded into memory",
+	})
+	metricLoadedPortals = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "bridge_portals_loaded",
+		Help: "Number of portal rooms loaded into memory",
+	})
+	metricLoadedPuppets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "bridge_puppets_loaded",
+		Help: "Number of puppets loaded into memory",
+	})
+	metricMatrixEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_matrix_events_total",
+		Help: "Number of Matrix events received, by event type and handling status",
+	}, []string{"type", "status"})
+	metricWhatsAppMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_whatsapp_messages_total",
+		Help: "Number of WhatsApp messages received, by handling status",
+	}, []string{"status"})
+	metricWhatsAppConnectionState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bridge_whatsapp_connection_state",
+		Help: "Current WhatsApp connection state per user (1 = in that state)",
+	}, []string{"mxid", "state"})
+	metricWhatsAppLogins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_whatsapp_logins_total",
+		Help: "Number of successful WhatsApp logins, by pairing method",
+	}, []string{"method"})
+	metricWhatsAppLogouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_whatsapp_logouts_total",
+		Help: "Number of WhatsApp logouts, by reason",
+	}, []string{"reason"})
+	metricBuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bridge_build_info",
+		Help: "Build information about the running bridge",
+	}, []string{"version", "commit"})
+	metricUptime = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "bridge_uptime_seconds",
+		Help: "Time in seconds since the bridge was started",
+	}, func() float64 {
+		return time.Since(startTime).Seconds()
+	})
+)
+
+var startTime = time.Now()
+
+func init() {
+	prometheus.MustRegister(
+		metricConnectedUsers, metricLoadedUsers, metricLoadedPortals, metricLoadedPuppets,
+		metricMatrixEvents, metricWhatsAppMessages, metricWhatsAppConnectionState,
+		metricWhatsAppLogins, metricWhatsAppLogouts, metricBuildInfo, metricUptime,
+	)
+}
+
+// Metrics serves Prometheus metrics for a running Bridge and keeps the
+// gauges that can't be updated inline (e.g. things derived from maps
+// protected by a mutex) up to date in the background.
+type Metrics struct {
+	bridge *Bridge
+	server *http.Server
+	stop   chan struct{}
+	log    log.Logger
+}
+
+func NewMetrics(bridge *Bridge) *Metrics {
+	return &Metrics{
+		bridge: bridge,
+		stop:   make(chan struct{}),
+		log:    bridge.Log.Sub("Metrics"),
+	}
+}
+
+// Start begins serving /metrics and refreshing the periodic gauges. It's a
+// no-op if metrics aren't enabled in the config.
+func (m *Metrics) Start() {
+	if !m.bridge.Config.Metrics.Enabled {
+		return
+	}
+	metricBuildInfo.WithLabelValues(Version, Commit).Set(1)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	m.server = &http.Server{Addr: m.bridge.Config.Metrics.Listen, Handler: mux}
+	m.log.Infoln("Starting metrics listener on", m.server.Addr)
+	go func() {
+		err := m.server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			m.log.Errorln("Error in metrics listener:", err)
+		}
+	}()
+
+	go m.refreshLoop()
+}
+
+func (m *Metrics) Stop() {
+	if m.server == nil {
+		return
+	}
+	close(m.stop)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.server.Shutdown(ctx); err != nil {
+		m.log.Warnln("Failed to close metrics listener:", err)
+	}
+}
+
+func (m *Metrics) refreshLoop() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.refresh()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *Metrics) refresh() {
+	m.bridge.usersLock.Lock()
+	connected := 0
+	for _, user := range m.bridge.usersByJID {
+		if user.Conn != nil {
+			connected++
+		}
+	}
+	loadedUsers := len(m.bridge.usersByJID)
+	m.bridge.usersLock.Unlock()
+	metricConnectedUsers.Set(float64(connected))
+	metricLoadedUsers.Set(float64(loadedUsers))
+
+	m.bridge.portalsLock.Lock()
+	metricLoadedPortals.Set(float64(len(m.bridge.portalsByJID)))
+	m.bridge.portalsLock.Unlock()
+
+	m.bridge.puppetsLock.Lock()
+	metricLoadedPuppets.Set(float64(len(m.bridge.puppets)))
+	m.bridge.puppetsLock.Unlock()
+}
+
+// TrackMatrixEvent records a Matrix event received from the appservice,
+// tagged with its type and whether it was handled successfully. Called from
+// the event instrumentation hooked into EventProcessor in instrumentation.go.
+func (m *Metrics) TrackMatrixEvent(eventType string, success bool) {
+	status := "ok"
+	if !success {
+		status = "error"
+	}
+	metricMatrixEvents.WithLabelValues(eventType, status).Inc()
+}
+
+// TrackWhatsAppMessage records a WhatsApp message received on a user's
+// connection. Called from User's message handler in user.go.
+func (m *Metrics) TrackWhatsAppMessage(success bool) {
+	status := "ok"
+	if !success {
+		status = "error"
+	}
+	metricWhatsAppMessages.WithLabelValues(status).Inc()
+}
+
+// TrackConnectionState records that mxid's WhatsApp connection entered
+// state ("connected" or "disconnected"), removing the user's series for the
+// other state so only the current one reads 1. Called from User.SetSession
+// in user.go.
+func (m *Metrics) TrackConnectionState(mxid, state string) {
+	for _, other := range []string{"connected", "disconnected"} {
+		if other != state {
+			metricWhatsAppConnectionState.DeleteLabelValues(mxid, other)
+		}
+	}
+	metricWhatsAppConnectionState.WithLabelValues(mxid, state).Set(1)
+}
+
+// TrackLogin records a successful WhatsApp pairing via method (e.g. "qr",
+// "restore"). Call sites for interactive QR/phone-number pairing live in the
+// Matrix command handler, which isn't part of this change.
+func (m *Metrics) TrackLogin(method string) {
+	metricWhatsAppLogins.WithLabelValues(method).Inc()
+}
+
+// TrackLogout records a WhatsApp logout via reason (e.g. "user", "banned").
+// Call sites for interactive logout commands live in the Matrix command
+// handler, which isn't part of this change.
+func (m *Metrics) TrackLogout(reason string) {
+	metricWhatsAppLogouts.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,140 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"maunium.net/go/mautrix-whatsapp/config"
+)
+
+// reloadLock makes sure a SIGHUP and a concurrent HTTP reload request can't
+// race each other while re-reading the config file.
+var reloadLock sync.Mutex
+
+// liveConfig holds the subset of config.Config that Reload is allowed to
+// swap out at runtime. bridge.Config itself is only ever assigned once, in
+// NewBridge, so it can be read from any goroutine without synchronization;
+// Reload publishes a new liveConfig instead of mutating bridge.Config's
+// fields, since other goroutines (Matrix event handlers, the formatter,
+// etc.) read those fields concurrently with no locking of their own.
+type liveConfig struct {
+	Bridge      config.BridgeConfig
+	Permissions config.PermissionConfig
+	Logging     config.LogConfig
+}
+
+// initLiveConfig seeds bridge.liveConfig from the config loaded at
+// startup. Must be called once from Init before Reload can be used.
+func (bridge *Bridge) initLiveConfig() {
+	bridge.liveConfig.Store(&liveConfig{
+		Bridge:      bridge.Config.Bridge,
+		Permissions: bridge.Config.Permissions,
+		Logging:     bridge.Config.Logging,
+	})
+}
+
+// BridgeConfig returns the current `bridge` section of the config (display
+// templates and the like), reflecting the most recent Reload.
+func (bridge *Bridge) BridgeConfig() config.BridgeConfig {
+	return bridge.liveConfig.Load().(*liveConfig).Bridge
+}
+
+// PermissionConfig returns the current permissions, reflecting the most
+// recent Reload.
+func (bridge *Bridge) PermissionConfig() config.PermissionConfig {
+	return bridge.liveConfig.Load().(*liveConfig).Permissions
+}
+
+// Reload re-reads the config file and swaps in everything that's safe to
+// change without dropping WhatsApp sessions or re-initializing portals:
+// permissions, the `bridge` section (display templates) and logging.
+// config.Config has no relay settings, so Reload swaps none.
+// Anything structural (database, appservice registration, listener
+// addresses, ...) is left untouched until the next restart.
+func (bridge *Bridge) Reload() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	bridge.Log.Infoln("Reloading configuration from", *configPath)
+	newConfig, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	bridge.liveConfig.Store(&liveConfig{
+		Bridge:      newConfig.Bridge,
+		Permissions: newConfig.Permissions,
+		Logging:     newConfig.Logging,
+	})
+
+	newConfig.Logging.Configure(bridge.Log)
+
+	bridge.Log.Infoln("Configuration reloaded")
+	return nil
+}
+
+// registerReloadRoute exposes Reload over the appservice HTTP server so
+// orchestration systems can trigger it without sending a signal.
+func (bridge *Bridge) registerReloadRoute() {
+	bridge.AS.Router.HandleFunc("/_matrix/bridge/reload", bridge.handleReload).Methods(http.MethodPost)
+}
+
+func (bridge *Bridge) handleReload(w http.ResponseWriter, r *http.Request) {
+	if !bridge.checkASToken(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "Missing or invalid access token"}`))
+		return
+	}
+
+	// The appservice access token only proves the caller holds the
+	// bridge's own as_token; the acting user's MXID is passed alongside it
+	// as user_id (the usual AS API convention), so check that user actually
+	// holds admin permissions before letting them trigger a reload.
+	requester := r.URL.Query().Get("user_id")
+	if len(requester) == 0 || bridge.PermissionConfig().GetPermissionLevel(requester) < config.PermissionAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error": "user_id is missing or not an admin"}`))
+		return
+	}
+
+	if err := bridge.Reload(); err != nil {
+		bridge.Log.Errorln("Failed to reload config via HTTP:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error": "Failed to reload config"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{}`))
+}
+
+// checkASToken validates that the request carries the appservice's own
+// access token (as_token, the credential the bridge uses to talk to the
+// homeserver), either as ?access_token= or as a Bearer token.
+func (bridge *Bridge) checkASToken(r *http.Request) bool {
+	token := r.URL.Query().Get("access_token")
+	if len(token) == 0 {
+		auth := r.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if len(auth) > len(prefix) && auth[:len(prefix)] == prefix {
+			token = auth[len(prefix):]
+		}
+	}
+	return token == bridge.Config.AppService.ASToken
+}
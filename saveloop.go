@@ -0,0 +1,67 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import "time"
+
+// saveLoopInterval is how often SaveLoop checks whether any in-memory state
+// has changed and needs to be persisted.
+const saveLoopInterval = 30 * time.Second
+
+// SaveLoop periodically persists users/portals/puppets that have changed
+// since the last save, and beats the "save_loop" health heartbeat every
+// iteration so a deadlocked or crashed loop is caught by /livez instead of
+// silently going stale.
+func (bridge *Bridge) SaveLoop() {
+	defer bridge.Health.MarkStopped("save_loop")
+	bridge.Health.Beat("save_loop")
+	ticker := time.NewTicker(saveLoopInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			bridge.saveChanged()
+			bridge.Health.Beat("save_loop")
+		case <-bridge.stopSaveLoop:
+			return
+		}
+	}
+}
+
+func (bridge *Bridge) saveChanged() {
+	if bridge.usersChanged {
+		if err := bridge.SaveUsers(); err != nil {
+			bridge.Log.Warnln("Failed to save users:", err)
+		} else {
+			bridge.usersChanged = false
+		}
+	}
+	if bridge.portalsChanged {
+		if err := bridge.SavePortals(); err != nil {
+			bridge.Log.Warnln("Failed to save portals:", err)
+		} else {
+			bridge.portalsChanged = false
+		}
+	}
+	if bridge.puppetsChanged {
+		if err := bridge.SavePuppets(); err != nil {
+			bridge.Log.Warnln("Failed to save puppets:", err)
+		} else {
+			bridge.puppetsChanged = false
+		}
+	}
+}
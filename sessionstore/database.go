@@ -0,0 +1,39 @@
+package sessionstore
+
+import (
+	"github.com/Rhymen/whatsapp"
+
+	"maunium.net/go/mautrix-whatsapp/database"
+)
+
+// databaseSessionStore stores sessions in the existing `user` table, which
+// is how the bridge behaved before pluggable session storage existed.
+type databaseSessionStore struct {
+	db *database.Database
+}
+
+func NewDatabaseSessionStore(db *database.Database) SessionStore {
+	return &databaseSessionStore{db: db}
+}
+
+func (s *databaseSessionStore) Load(mxid string) (*whatsapp.Session, error) {
+	user := s.db.User.Get(mxid)
+	if user == nil {
+		return nil, nil
+	}
+	return user.Session, nil
+}
+
+func (s *databaseSessionStore) Save(mxid string, session *whatsapp.Session) error {
+	user := s.db.User.Get(mxid)
+	if user == nil {
+		return nil
+	}
+	user.Session = session
+	user.Update()
+	return nil
+}
+
+func (s *databaseSessionStore) Delete(mxid string) error {
+	return s.Save(mxid, nil)
+}
@@ -0,0 +1,137 @@
+package sessionstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Rhymen/whatsapp"
+)
+
+// FileConfig configures the encrypted-at-rest file session store. The
+// encryption key is never read from the config file itself - only from
+// an environment variable (or whatever populates it, e.g. a KMS-backed
+// secrets injector) - so that session credentials can't leak via a
+// config file backup. The env var must contain the key hex-encoded
+// (64 hex characters for AES-256), since raw 32-byte binary doesn't
+// survive round-tripping through most env var mechanisms intact.
+type FileConfig struct {
+	Directory string `yaml:"directory"`
+	KeyEnvVar string `yaml:"key_env_var"`
+}
+
+type fileSessionStore struct {
+	dir string
+	key []byte
+}
+
+func NewFileSessionStore(cfg FileConfig) (SessionStore, error) {
+	keyEnvVar := cfg.KeyEnvVar
+	if len(keyEnvVar) == 0 {
+		keyEnvVar = "WHATSAPP_SESSION_STORE_KEY"
+	}
+	keyHex := os.Getenv(keyEnvVar)
+	if len(keyHex) == 0 {
+		return nil, fmt.Errorf("%s is not set", keyEnvVar)
+	}
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be hex-encoded: %w", keyEnvVar, err)
+	} else if len(key) != 32 {
+		return nil, fmt.Errorf("%s must decode to a 32-byte key", keyEnvVar)
+	}
+	if err = os.MkdirAll(cfg.Directory, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session store directory: %w", err)
+	}
+	return &fileSessionStore{dir: cfg.Directory, key: key}, nil
+}
+
+func (s *fileSessionStore) path(mxid string) string {
+	return filepath.Join(s.dir, mxid+".session")
+}
+
+func (s *fileSessionStore) Load(mxid string) (*whatsapp.Session, error) {
+	ciphertext, err := ioutil.ReadFile(s.path(mxid))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	plaintext, err := s.decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session file: %w", err)
+	}
+
+	var session whatsapp.Session
+	if err = json.Unmarshal(plaintext, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session file: %w", err)
+	}
+	return &session, nil
+}
+
+func (s *fileSessionStore) Save(mxid string, session *whatsapp.Session) error {
+	if session == nil {
+		return s.Delete(mxid)
+	}
+	plaintext, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to serialize session: %w", err)
+	}
+	ciphertext, err := s.encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt session: %w", err)
+	}
+	if err = ioutil.WriteFile(s.path(mxid), ciphertext, 0600); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+func (s *fileSessionStore) Delete(mxid string) error {
+	err := os.Remove(s.path(mxid))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete session file: %w", err)
+	}
+	return nil
+}
+
+func (s *fileSessionStore) encrypt(plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (s *fileSessionStore) decrypt(ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, data := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	return gcm.Open(nil, nonce, data, nil)
+}
@@ -0,0 +1,101 @@
+package sessionstore
+
+import (
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/Rhymen/whatsapp"
+)
+
+const testKeyEnvVar = "TEST_WHATSAPP_SESSION_STORE_KEY"
+
+func withKeyEnv(t *testing.T, value string) {
+	t.Helper()
+	old, hadOld := os.LookupEnv(testKeyEnvVar)
+	if len(value) == 0 {
+		_ = os.Unsetenv(testKeyEnvVar)
+	} else {
+		_ = os.Setenv(testKeyEnvVar, value)
+	}
+	t.Cleanup(func() {
+		if hadOld {
+			_ = os.Setenv(testKeyEnvVar, old)
+		} else {
+			_ = os.Unsetenv(testKeyEnvVar)
+		}
+	})
+}
+
+func TestNewFileSessionStore_RejectsNonHexKey(t *testing.T) {
+	withKeyEnv(t, "not-hex-and-also-not-32-bytes-long!!")
+	_, err := NewFileSessionStore(FileConfig{Directory: t.TempDir(), KeyEnvVar: testKeyEnvVar})
+	if err == nil {
+		t.Fatal("expected an error for a non-hex key, got nil")
+	}
+}
+
+func TestNewFileSessionStore_RejectsWrongLength(t *testing.T) {
+	withKeyEnv(t, hex.EncodeToString([]byte("too-short")))
+	_, err := NewFileSessionStore(FileConfig{Directory: t.TempDir(), KeyEnvVar: testKeyEnvVar})
+	if err == nil {
+		t.Fatal("expected an error for a key that doesn't decode to 32 bytes, got nil")
+	}
+}
+
+func TestFileSessionStore_RoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	withKeyEnv(t, hex.EncodeToString(key))
+
+	store, err := NewFileSessionStore(FileConfig{Directory: t.TempDir(), KeyEnvVar: testKeyEnvVar})
+	if err != nil {
+		t.Fatalf("failed to create file session store: %v", err)
+	}
+
+	session := &whatsapp.Session{
+		ClientId:    "test-client-id",
+		ClientToken: "test-client-token",
+	}
+	if err = store.Save("@user:example.com", session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	loaded, err := store.Load("@user:example.com")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	} else if loaded == nil {
+		t.Fatal("expected a session, got nil")
+	} else if loaded.ClientId != session.ClientId || loaded.ClientToken != session.ClientToken {
+		t.Fatalf("loaded session %+v does not match saved session %+v", loaded, session)
+	}
+
+	if err = store.Delete("@user:example.com"); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	loaded, err = store.Load("@user:example.com")
+	if err != nil {
+		t.Fatalf("failed to load deleted session: %v", err)
+	} else if loaded != nil {
+		t.Fatal("expected nil session after delete")
+	}
+}
+
+func TestFileSessionStore_LoadMissing(t *testing.T) {
+	key := make([]byte, 32)
+	withKeyEnv(t, hex.EncodeToString(key))
+
+	store, err := NewFileSessionStore(FileConfig{Directory: t.TempDir(), KeyEnvVar: testKeyEnvVar})
+	if err != nil {
+		t.Fatalf("failed to create file session store: %v", err)
+	}
+
+	session, err := store.Load("@nobody:example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if session != nil {
+		t.Fatal("expected nil session for a user that was never saved")
+	}
+}
@@ -0,0 +1,71 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package sessionstore abstracts where WhatsApp session credentials
+// (the Session returned by Conn.Login/Conn.Restore) are persisted, so
+// they don't have to live in the same Postgres/SQLite database as the
+// rest of the bridge state.
+package sessionstore
+
+import (
+	"fmt"
+
+	"github.com/Rhymen/whatsapp"
+
+	"maunium.net/go/mautrix-whatsapp/database"
+)
+
+// SessionStore loads and saves WhatsApp sessions for a given Matrix user,
+// keyed by their Matrix ID.
+type SessionStore interface {
+	// Load returns the stored session for mxid, or nil if there isn't one.
+	Load(mxid string) (*whatsapp.Session, error)
+	// Save persists session for mxid, overwriting any previous value.
+	Save(mxid string, session *whatsapp.Session) error
+	// Delete removes any stored session for mxid.
+	Delete(mxid string) error
+}
+
+// Backend identifies which SessionStore implementation to use.
+type Backend string
+
+const (
+	BackendDatabase Backend = "database"
+	BackendVault    Backend = "vault"
+	BackendFile     Backend = "file"
+)
+
+// Config is the `bridge.session_store` section of config.Config.
+type Config struct {
+	Backend Backend `yaml:"backend"`
+
+	Vault VaultConfig `yaml:"vault"`
+	File  FileConfig  `yaml:"file"`
+}
+
+// New constructs the SessionStore selected by cfg.Backend.
+func New(cfg Config, db *database.Database) (SessionStore, error) {
+	switch cfg.Backend {
+	case "", BackendDatabase:
+		return NewDatabaseSessionStore(db), nil
+	case BackendVault:
+		return NewVaultSessionStore(cfg.Vault)
+	case BackendFile:
+		return NewFileSessionStore(cfg.File)
+	default:
+		return nil, fmt.Errorf("unknown session store backend %q", cfg.Backend)
+	}
+}
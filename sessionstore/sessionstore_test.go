@@ -0,0 +1,27 @@
+package sessionstore
+
+import "testing"
+
+func TestNew_DefaultsToDatabaseBackend(t *testing.T) {
+	store, err := New(Config{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := store.(*databaseSessionStore); !ok {
+		t.Fatalf("expected a *databaseSessionStore for an empty backend, got %T", store)
+	}
+}
+
+func TestNew_UnknownBackend(t *testing.T) {
+	_, err := New(Config{Backend: "carrier-pigeon"}, nil)
+	if err == nil {
+		t.Fatal("expected an error for an unknown backend, got nil")
+	}
+}
+
+func TestNew_FileBackendPropagatesConfigErrors(t *testing.T) {
+	_, err := New(Config{Backend: BackendFile, File: FileConfig{Directory: t.TempDir(), KeyEnvVar: "TEST_SESSIONSTORE_NEW_MISSING_KEY"}}, nil)
+	if err == nil {
+		t.Fatal("expected an error when the file backend's key env var is unset")
+	}
+}
@@ -0,0 +1,88 @@
+package sessionstore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"github.com/Rhymen/whatsapp"
+)
+
+// VaultConfig configures access to a HashiCorp Vault KV v2 mount used to
+// store WhatsApp sessions.
+type VaultConfig struct {
+	Address    string `yaml:"address"`
+	Token      string `yaml:"token"`
+	Mount      string `yaml:"mount"`
+	PathPrefix string `yaml:"path_prefix"`
+}
+
+type vaultSessionStore struct {
+	client *vaultapi.Client
+	cfg    VaultConfig
+}
+
+func NewVaultSessionStore(cfg VaultConfig) (SessionStore, error) {
+	clientCfg := vaultapi.DefaultConfig()
+	clientCfg.Address = cfg.Address
+	client, err := vaultapi.NewClient(clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client: %w", err)
+	}
+	client.SetToken(cfg.Token)
+	return &vaultSessionStore{client: client, cfg: cfg}, nil
+}
+
+func (s *vaultSessionStore) path(mxid string) string {
+	return fmt.Sprintf("%s/data/%s/%s", s.cfg.Mount, s.cfg.PathPrefix, mxid)
+}
+
+func (s *vaultSessionStore) Load(mxid string) (*whatsapp.Session, error) {
+	secret, err := s.client.Logical().Read(s.path(mxid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session from vault: %w", err)
+	} else if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	raw, ok := data["session"].(string)
+	if !ok {
+		return nil, nil
+	}
+	var session whatsapp.Session
+	if err = json.Unmarshal([]byte(raw), &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session from vault: %w", err)
+	}
+	return &session, nil
+}
+
+func (s *vaultSessionStore) Save(mxid string, session *whatsapp.Session) error {
+	if session == nil {
+		return s.Delete(mxid)
+	}
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to serialize session: %w", err)
+	}
+	_, err = s.client.Logical().Write(s.path(mxid), map[string]interface{}{
+		"data": map[string]interface{}{
+			"session": string(raw),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write session to vault: %w", err)
+	}
+	return nil
+}
+
+func (s *vaultSessionStore) Delete(mxid string) error {
+	_, err := s.client.Logical().Delete(s.path(mxid))
+	if err != nil {
+		return fmt.Errorf("failed to delete session from vault: %w", err)
+	}
+	return nil
+}
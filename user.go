@@ -0,0 +1,146 @@
+// mautrix-whatsapp - A Matrix-WhatsApp puppeting bridge.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/Rhymen/whatsapp"
+
+	log "maunium.net/go/maulogger/v2"
+
+	"maunium.net/go/mautrix-whatsapp/database"
+	"maunium.net/go/mautrix-whatsapp/types"
+)
+
+// User wraps a single Matrix user's WhatsApp connection. The MXID/JID
+// identity mapping lives in database.User; the WhatsApp session credentials
+// live in whatever backend bridge.SessionStore is configured with (see
+// package sessionstore), not in the users table.
+type User struct {
+	MXID types.MatrixUserID
+	JID  types.WhatsAppID
+	Conn *whatsapp.Conn
+
+	// Session is this user's most recently known WhatsApp session,
+	// restored from bridge.SessionStore at load time and kept up to date
+	// by SetSession. Nothing in this file turns it into a live Conn.
+	Session *whatsapp.Session
+
+	bridge *Bridge
+	log    log.Logger
+}
+
+func (bridge *Bridge) loadDBUser(dbUser *database.User) *User {
+	user := &User{
+		MXID:   dbUser.MXID,
+		JID:    dbUser.JID,
+		bridge: bridge,
+		log:    bridge.Log.Sub("User").Sub(string(dbUser.MXID)),
+	}
+	session, err := bridge.SessionStore.Load(string(user.MXID))
+	if err != nil {
+		user.log.Errorln("Failed to load WhatsApp session:", err)
+	} else {
+		user.Session = session
+	}
+	return user
+}
+
+// LoadUsers populates the bridge's user maps from the database, restoring
+// each user's WhatsApp session from bridge.SessionStore instead of reading
+// it out of the users table directly.
+func (bridge *Bridge) LoadUsers() error {
+	dbUsers := bridge.DB.User.GetAll()
+
+	bridge.usersLock.Lock()
+	defer bridge.usersLock.Unlock()
+	for _, dbUser := range dbUsers {
+		user := bridge.loadDBUser(dbUser)
+		bridge.usersByMXID[user.MXID] = user
+		if len(user.JID) > 0 {
+			bridge.usersByJID[user.JID] = user
+		}
+	}
+	return nil
+}
+
+// GetAllUsers returns every user currently loaded into memory.
+func (bridge *Bridge) GetAllUsers() []*User {
+	bridge.usersLock.Lock()
+	defer bridge.usersLock.Unlock()
+	users := make([]*User, 0, len(bridge.usersByMXID))
+	for _, user := range bridge.usersByMXID {
+		users = append(users, user)
+	}
+	return users
+}
+
+// SaveUsers persists every loaded user's current WhatsApp session to
+// bridge.SessionStore. The MXID/JID mapping itself still lives in the
+// database and isn't touched here.
+func (bridge *Bridge) SaveUsers() error {
+	bridge.usersLock.Lock()
+	defer bridge.usersLock.Unlock()
+	for _, user := range bridge.usersByMXID {
+		if user.Session == nil {
+			continue
+		}
+		if err := bridge.SessionStore.Save(string(user.MXID), user.Session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSession updates the user's known session, persists it via
+// bridge.SessionStore (deleting it if session is nil, e.g. on logout),
+// and records the resulting connection state for metrics.
+func (user *User) SetSession(session *whatsapp.Session) {
+	user.Session = session
+
+	var err error
+	if session == nil {
+		err = user.bridge.SessionStore.Delete(string(user.MXID))
+	} else {
+		err = user.bridge.SessionStore.Save(string(user.MXID), session)
+	}
+	if err != nil {
+		user.log.Errorln("Failed to persist WhatsApp session:", err)
+	}
+
+	state := "disconnected"
+	if session != nil {
+		state = "connected"
+	}
+	user.bridge.Metrics.TrackConnectionState(string(user.MXID), state)
+}
+
+// Connect returns immediately if user.Session is nil and otherwise only
+// logs a debug message: it does not open a WhatsApp connection or set
+// user.Conn, and override has no effect.
+func (user *User) Connect(override bool) {
+	if user.Session == nil {
+		return
+	}
+	user.log.Debugln("Connect is a stub in this change; WhatsApp protocol handling is out of scope here")
+}
+
+// HandleTextMessage is registered as a whatsapp.Conn message handler.
+// Relaying the message into Matrix happens in the Matrix handler, which
+// isn't part of this change; this just keeps the message metrics honest.
+func (user *User) HandleTextMessage(message whatsapp.TextMessage) {
+	user.bridge.Metrics.TrackWhatsAppMessage(true)
+}